@@ -0,0 +1,248 @@
+package bencode
+
+import (
+	"bytes"
+	"fmt"
+)
+
+//Any is a lazy view over an undecoded bencode value, modeled on
+//json-iterator's Any. It never decodes more of data than a call
+//actually asks for: Get skip-scans straight to the requested key or
+//index using the same tokenizer as Stream, without allocating the
+//maps/slices a full Decode would. This makes it cheap to read one field
+//out of a huge multi-file torrent (e.g. "announce" or "info.name")
+//without paying to decode its "files" list or "pieces" string.
+type Any struct {
+	data []byte
+	err  error
+}
+
+//ParseAny wraps data, the bencode encoding of a single value, as an
+//Any. Nothing is parsed until a Get/To*/Keys/Size call.
+func ParseAny(data []byte) *Any {
+	return &Any{data: data}
+}
+
+//LastError returns the first error encountered while navigating to or
+//reading this Any, or nil.
+func (a *Any) LastError() error {
+	return a.err
+}
+
+//Raw returns the value's exact bencode encoding, unparsed. It's empty
+//once a is already carrying an error; check LastError first.
+func (a *Any) Raw() []byte {
+	if a.err != nil {
+		return nil
+	}
+	return a.data
+}
+
+//Get descends into a dict (string key) or list (int index) for each key
+//in turn, returning the Any at that sub-tree. Once any step fails, the
+//error is latched and subsequent Get/To* calls are no-ops that return
+//zero values; check LastError to find out what went wrong.
+func (a *Any) Get(keys ...interface{}) *Any {
+	cur := a
+	for _, k := range keys {
+		cur = cur.get(k)
+		if cur.err != nil {
+			return cur
+		}
+	}
+	return cur
+}
+
+func (a *Any) get(key interface{}) *Any {
+	if a.err != nil {
+		return a
+	}
+	s := NewStream(bytes.NewReader(a.data))
+
+	switch k := key.(type) {
+	case string:
+		if err := s.Dict(); err != nil {
+			return &Any{err: err}
+		}
+		for {
+			kind, err := s.Kind()
+			if err != nil {
+				return &Any{err: err}
+			}
+			if kind == End {
+				return &Any{err: fmt.Errorf("bencode: no key %q in dict", k)}
+			}
+			dk, err := s.Bytes()
+			if err != nil {
+				return &Any{err: err}
+			}
+			if string(dk) == k {
+				raw, err := s.Raw()
+				if err != nil {
+					return &Any{err: err}
+				}
+				return &Any{data: raw}
+			}
+			if err := s.skip(); err != nil {
+				return &Any{err: err}
+			}
+		}
+
+	case int:
+		if err := s.List(); err != nil {
+			return &Any{err: err}
+		}
+		for i := 0; ; i++ {
+			kind, err := s.Kind()
+			if err != nil {
+				return &Any{err: err}
+			}
+			if kind == End {
+				return &Any{err: fmt.Errorf("bencode: index %d out of range", k)}
+			}
+			if i == k {
+				raw, err := s.Raw()
+				if err != nil {
+					return &Any{err: err}
+				}
+				return &Any{data: raw}
+			}
+			if err := s.skip(); err != nil {
+				return &Any{err: err}
+			}
+		}
+
+	default:
+		return &Any{err: fmt.Errorf("bencode: Get key must be string or int, got %T", key)}
+	}
+}
+
+//ToInt decodes the value as a bencode integer. It returns 0 if the
+//value isn't an integer or a is already carrying an error.
+func (a *Any) ToInt() int64 {
+	if a.err != nil {
+		return 0
+	}
+	i, err := NewStream(bytes.NewReader(a.data)).Int()
+	if err != nil {
+		a.err = err
+		return 0
+	}
+	return i
+}
+
+//ToString decodes the value as a bencode byte string. It returns "" if
+//the value isn't a string or a is already carrying an error.
+func (a *Any) ToString() string {
+	if a.err != nil {
+		return ""
+	}
+	b, err := NewStream(bytes.NewReader(a.data)).Bytes()
+	if err != nil {
+		a.err = err
+		return ""
+	}
+	return string(b)
+}
+
+//Keys returns the keys of a dict value, in their on-disk order. It
+//returns nil if the value isn't a dict or a is already carrying an
+//error.
+func (a *Any) Keys() []string {
+	if a.err != nil {
+		return nil
+	}
+	s := NewStream(bytes.NewReader(a.data))
+	if err := s.Dict(); err != nil {
+		a.err = err
+		return nil
+	}
+	var keys []string
+	for {
+		kind, err := s.Kind()
+		if err != nil {
+			a.err = err
+			return nil
+		}
+		if kind == End {
+			return keys
+		}
+		k, err := s.Bytes()
+		if err != nil {
+			a.err = err
+			return nil
+		}
+		keys = append(keys, string(k))
+		if err := s.skip(); err != nil {
+			a.err = err
+			return nil
+		}
+	}
+}
+
+//Size returns the number of elements in a list, or the number of keys
+//in a dict, or 0 for a scalar or an Any already carrying an error.
+func (a *Any) Size() int {
+	if a.err != nil {
+		return 0
+	}
+	s := NewStream(bytes.NewReader(a.data))
+	kind, err := s.Kind()
+	if err != nil {
+		a.err = err
+		return 0
+	}
+
+	switch kind {
+	case List:
+		if err := s.List(); err != nil {
+			a.err = err
+			return 0
+		}
+		n := 0
+		for {
+			kind, err := s.Kind()
+			if err != nil {
+				a.err = err
+				return 0
+			}
+			if kind == End {
+				return n
+			}
+			if err := s.skip(); err != nil {
+				a.err = err
+				return 0
+			}
+			n++
+		}
+
+	case Dict:
+		if err := s.Dict(); err != nil {
+			a.err = err
+			return 0
+		}
+		n := 0
+		for {
+			kind, err := s.Kind()
+			if err != nil {
+				a.err = err
+				return 0
+			}
+			if kind == End {
+				return n
+			}
+			if _, err := s.Bytes(); err != nil { //key
+				a.err = err
+				return 0
+			}
+			if err := s.skip(); err != nil { //value
+				a.err = err
+				return 0
+			}
+			n++
+		}
+
+	default:
+		return 0
+	}
+}
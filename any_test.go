@@ -0,0 +1,85 @@
+package bencode
+
+import "testing"
+
+func anyTestTorrent(t *testing.T) []byte {
+	data, err := Marshal(map[string]interface{}{
+		"announce": "udp://foo",
+		"info": map[string]interface{}{
+			"name": "movie",
+			"files": []interface{}{
+				[]interface{}{int64(111), "a"},
+				[]interface{}{int64(222), "b"},
+			},
+			"pieces": []byte{1, 2, 3, 4},
+		},
+	})
+	if err != nil {
+		t.Fatalf("building fixture: %v", err)
+	}
+	return data
+}
+
+func TestAnyGetScalar(t *testing.T) {
+	a := ParseAny(anyTestTorrent(t))
+	if got, want := a.Get("announce").ToString(), "udp://foo"; got != want {
+		t.Fatalf("Get(announce) = %q; want %q", got, want)
+	}
+	if got, want := a.Get("info", "name").ToString(), "movie"; got != want {
+		t.Fatalf("Get(info,name) = %q; want %q", got, want)
+	}
+}
+
+func TestAnyGetNestedListOfLists(t *testing.T) {
+	a := ParseAny(anyTestTorrent(t))
+	files := a.Get("info", "files")
+	if err := files.LastError(); err != nil {
+		t.Fatal(err)
+	}
+	if n := files.Size(); n != 2 {
+		t.Fatalf("Size() = %d; want 2", n)
+	}
+	if got := files.Get(0, 0).ToInt(); got != 111 {
+		t.Fatalf("Get(0,0) = %d; want 111", got)
+	}
+	if got := files.Get(1, 0).ToInt(); got != 222 {
+		t.Fatalf("Get(1,0) = %d; want 222", got)
+	}
+}
+
+func TestAnyGetMissingKey(t *testing.T) {
+	a := ParseAny(anyTestTorrent(t)).Get("info", "nonexistent")
+	if a.LastError() == nil {
+		t.Fatal("Get with a missing key = nil LastError; want an error")
+	}
+	if got := a.ToString(); got != "" {
+		t.Fatalf("ToString() after a failed Get = %q; want \"\"", got)
+	}
+}
+
+func TestAnyGetOutOfRangeIndex(t *testing.T) {
+	a := ParseAny(anyTestTorrent(t)).Get("info", "files").Get(5)
+	if a.LastError() == nil {
+		t.Fatal("Get with an out-of-range index = nil LastError; want an error")
+	}
+}
+
+func TestAnyKeys(t *testing.T) {
+	keys := ParseAny(anyTestTorrent(t)).Get("info").Keys()
+	want := map[string]bool{"name": true, "files": true, "pieces": true}
+	if len(keys) != len(want) {
+		t.Fatalf("Keys() = %v; want %d keys", keys, len(want))
+	}
+	for _, k := range keys {
+		if !want[k] {
+			t.Errorf("Keys() contains unexpected key %q", k)
+		}
+	}
+}
+
+func TestAnyGetOnMalformedInput(t *testing.T) {
+	a := ParseAny([]byte("d4:infol1:a")).Get("info")
+	if a.LastError() == nil {
+		t.Fatal("Get on truncated input = nil LastError; want an error")
+	}
+}
@@ -31,7 +31,7 @@ type Decoder struct {
 
 //NewDecoder creates a new decoder for the given token stream
 func NewDecoder(b []byte) *Decoder {
-	return &Decoder{b, 0, false, 0}
+	return &Decoder{stream: b}
 }
 
 //Decode reads one object from the input stream
@@ -7,117 +7,77 @@
 package bencode
 
 import (
-	"fmt"
+	"bytes"
+	"io"
 	"reflect"
-	"sort"
 )
 
 //Encoder takes care of encoding objects into byte streams.
 //The result of the encoding operation is available in Encoder.Bytes.
 //Consecutive operations are appended to the byte stream.
 //
-//Accepts only string, int/int64, []interface{}, and map[string]interface{} as input.
+//Encoder accepts the same values Marshal does: string, []byte, int/uint
+//of any width, structs with `bencode` tags, types implementing
+//Marshaler, and arbitrary slices and string-keyed maps (so a
+//Decoder.Decode result can be re-encoded without a typed struct). Dict
+//keys are sorted lexicographically on their raw bytes, as BEP-3
+//requires; invalid input produces an error rather than a panic.
 type Encoder struct {
 	Bytes []byte //the result byte stream
+
+	//Strict additionally rejects a Marshaler's output if it isn't in
+	//bencode's single canonical form (no "-0", no leading zeros).
+	Strict bool
 }
 
+//NewEncoder creates a new Encoder.
 func NewEncoder() *Encoder {
 	return &Encoder{}
 }
 
 //Encode is a wrapper for Encoder.Encode.
 //It returns the bencoded byte stream.
-func Encode(in interface{}) []byte {
+func Encode(in interface{}) ([]byte, error) {
 	enc := NewEncoder()
-	enc.Encode(in)
-	return enc.Bytes
+	if err := enc.Encode(in); err != nil {
+		return nil, err
+	}
+	return enc.Bytes, nil
 }
 
-//Encode encodes an object into a bencoded byte stream.
-//The result of the operation is accessible through Encoder.Bytes.
+//Encode encodes an object into a bencoded byte stream, appending the
+//result to Encoder.Bytes.
 //
 //Example:
 //	enc.Encode(23)
 //	enc.Encode("test")
 //	enc.Bytes //contains 'i23e4:test'
-func (enc *Encoder) Encode(in interface{}) {
-	b := enc.encodeObject(in)
-	if len(b) > 0 {
-		enc.Bytes = append(enc.Bytes, b...)
-	}
-}
-
-func (enc *Encoder) encodeObject(in interface{}) []byte {
-	switch reflect.TypeOf(in).Kind() {
-	case reflect.String:
-		return enc.encodeString(in.(string))
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		t := reflect.ValueOf(in)
-		return enc.encodeUinteger(t.Uint())
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		t := reflect.ValueOf(in)
-		return enc.encodeInteger(t.Int())
-	case reflect.Slice:
-		return enc.encodeList(in.([]interface{}))
-	case reflect.Map:
-		//fmt.Printf("encoding map:%+v\n", in)
-		return enc.encodeDict(in.(map[string]interface{}))
-	default:
-		panic("Can't encode this type: " + reflect.TypeOf(in).Name())
+func (enc *Encoder) Encode(in interface{}) error {
+	var buf bytes.Buffer
+	if err := marshalValue(&buf, reflect.ValueOf(in), enc.Strict); err != nil {
+		return err
 	}
+	enc.Bytes = append(enc.Bytes, buf.Bytes()...)
 	return nil
 }
 
-func (enc *Encoder) encodeString(s string) []byte {
-	l := len(s)
-	if l <= 0 {
-		ret := fmt.Sprintf("%d:", l)
-		return []byte(ret)
-	}
-	ret := fmt.Sprintf("%d:%s", l, s)
-	return []byte(ret)
-}
+//StreamEncoder is an io.Writer-backed encoder: Encode writes the
+//bencode encoding of its argument straight to the underlying writer
+//instead of buffering the whole result the way Encoder.Bytes does, so
+//encoding a large .torrent doesn't hold two copies of it in memory.
+type StreamEncoder struct {
+	w io.Writer
 
-func (enc *Encoder) encodeInteger(i int64) []byte {
-	ret := fmt.Sprintf("i%de", i)
-	return []byte(ret)
-}
-func (enc *Encoder) encodeUinteger(i uint64) []byte {
-	ret := fmt.Sprintf("i%de", i)
-	return []byte(ret)
+	//Strict has the same meaning as Encoder.Strict.
+	Strict bool
 }
 
-func (enc *Encoder) encodeList(list []interface{}) []byte {
-	if len(list) <= 0 {
-		return nil
-	}
-	ret := []byte("l")
-	for i := 0; i < len(list); i++ {
-		o := list[i]
-		ret = append(ret, enc.encodeObject(o)...)
-	}
-	ret = append(ret, 'e')
-	return ret
+//NewStreamEncoder creates a StreamEncoder that writes to w.
+func NewStreamEncoder(w io.Writer) *StreamEncoder {
+	return &StreamEncoder{w: w}
 }
 
-func (enc *Encoder) encodeDict(m map[string]interface{}) []byte {
-	if len(m) <= 0 {
-		return nil
-	}
-	//sort the map >.<
-	var keys []string
-	for k, _ := range m {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-
-	ret := []byte("d")
-	for _, k := range keys {
-		v := m[k]
-		//fmt.Printf("KEY: %+v, VALUE: %+v\n", k, v)
-		ret = append(ret, enc.encodeString(k)...)
-		ret = append(ret, enc.encodeObject(v)...)
-	}
-	ret = append(ret, 'e')
-	return ret
+//Encode writes the bencode encoding of in to the underlying writer.
+func (enc *StreamEncoder) Encode(in interface{}) error {
+	return marshalValue(enc.w, reflect.ValueOf(in), enc.Strict)
 }
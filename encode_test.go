@@ -0,0 +1,91 @@
+package bencode
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeEmptyContainers(t *testing.T) {
+	b, err := Encode(map[string]interface{}{
+		"l": []interface{}{},
+		"d": map[string]interface{}{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "d1:dde1:llee"
+	if string(b) != want {
+		t.Fatalf("Encode() = %q; want %q", b, want)
+	}
+}
+
+func TestEncodeKeysSortedByRawBytes(t *testing.T) {
+	//"\xff" sorts after "z" by raw byte value; a naive UTF-8 string
+	//sort would get this wrong for non-ASCII keys.
+	b, err := Encode(map[string]interface{}{
+		"z":    1,
+		"\xff": 2,
+		"a":    3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "d1:ai3e1:zi1e1:\xffi2ee"
+	if string(b) != want {
+		t.Fatalf("Encode() = %q; want %q", b, want)
+	}
+}
+
+func TestEncodeRejectsUnsupportedType(t *testing.T) {
+	if _, err := Encode(make(chan int)); err == nil {
+		t.Fatal("Encode(chan int) = nil error; want error")
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	in := map[string]interface{}{
+		"announce": "udp://foo",
+		"files":    []interface{}{"a", "b"},
+	}
+	b, err := Encode(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := NewDecoder(b).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b2, err := Encode(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, b2) {
+		t.Fatalf("decode->encode round trip = %q; want %q", b2, b)
+	}
+}
+
+type nonCanonicalMarshaler struct{}
+
+func (nonCanonicalMarshaler) MarshalBencode() ([]byte, error) {
+	return []byte("i007e"), nil //leading zeros: not canonical
+}
+
+func TestStreamEncoderStrictRejectsNonCanonicalMarshaler(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewStreamEncoder(&buf)
+	enc.Strict = true
+	if err := enc.Encode(nonCanonicalMarshaler{}); err == nil {
+		t.Fatal("Strict Encode of non-canonical Marshaler output = nil error; want error")
+	}
+}
+
+func TestStreamEncoderNonStrictAllowsNonCanonicalMarshaler(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewStreamEncoder(&buf)
+	if err := enc.Encode(nonCanonicalMarshaler{}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "i007e" {
+		t.Fatalf("Encode() wrote %q; want %q", buf.String(), "i007e")
+	}
+}
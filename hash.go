@@ -0,0 +1,21 @@
+package bencode
+
+import (
+	"crypto/sha1"
+)
+
+//InfoHash returns the BitTorrent info-hash of a bencoded .torrent or
+//DHT payload in data: the SHA-1 of the raw, still-encoded bytes of its
+//top-level "info" value. It skip-scans to "info" with Any instead of
+//re-encoding the decoded dict, since the info-hash must match the
+//original bytes exactly and a decode/re-encode round trip isn't
+//guaranteed to be byte-identical for malformed or non-canonical input.
+//Any is built on Stream, so malformed or truncated input is reported as
+//an error rather than a panic.
+func InfoHash(data []byte) ([20]byte, error) {
+	info := ParseAny(data).Get("info")
+	if err := info.LastError(); err != nil {
+		return [20]byte{}, err
+	}
+	return sha1.Sum(info.Raw()), nil
+}
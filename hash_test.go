@@ -0,0 +1,39 @@
+package bencode
+
+import (
+	"crypto/sha1"
+	"testing"
+)
+
+func TestInfoHash(t *testing.T) {
+	data := []byte("d4:infod4:name3:foo6:lengthi10eee")
+	want := sha1.Sum([]byte("d4:name3:foo6:lengthi10ee"))
+
+	got, err := InfoHash(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Fatalf("InfoHash() = %x; want %x", got, want)
+	}
+}
+
+func TestInfoHashMissingKey(t *testing.T) {
+	if _, err := InfoHash([]byte("d7:comment3:foie")); err == nil {
+		t.Fatal("InfoHash with no \"info\" key = nil error; want error")
+	}
+}
+
+func TestInfoHashMalformed(t *testing.T) {
+	cases := []string{
+		"d4:infol1:a", //unterminated list as the info value
+		"not bencode", //not even a dict
+		"",            //empty input
+		"d4:info",     //dict with a key but no value
+	}
+	for _, c := range cases {
+		if _, err := InfoHash([]byte(c)); err == nil {
+			t.Errorf("InfoHash(%q) = nil error; want error", c)
+		}
+	}
+}
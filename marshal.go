@@ -0,0 +1,244 @@
+package bencode
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+//Marshaler is implemented by types that can encode themselves into a
+//valid bencode value, e.g. a big.Int or a custom hash type that wants
+//to be written as a byte string rather than its Go struct layout.
+type Marshaler interface {
+	MarshalBencode() ([]byte, error)
+}
+
+//Unmarshaler is implemented by types that can decode themselves from a
+//value's raw, still-encoded bencode, as produced by Stream.Raw.
+type Unmarshaler interface {
+	UnmarshalBencode([]byte) error
+}
+
+var (
+	marshalerType   = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+)
+
+//addrInterface reports whether rv, or a pointer to rv if rv is
+//addressable, implements iface, returning that value as an interface{}
+//so the caller can type-assert it. This lets a method with a pointer
+//receiver (common for UnmarshalBencode) be reached from a plain,
+//addressable struct field.
+func addrInterface(rv reflect.Value, iface reflect.Type) (interface{}, bool) {
+	if rv.Type().Implements(iface) {
+		return rv.Interface(), true
+	}
+	if rv.CanAddr() && reflect.PtrTo(rv.Type()).Implements(iface) {
+		return rv.Addr().Interface(), true
+	}
+	return nil, false
+}
+
+//Marshal returns the bencode encoding of v. Struct fields are encoded
+//using the same `bencode:"name,omitempty"` tags, embedding, and
+//Marshaler rules that Unmarshal applies in reverse. It's equivalent to
+//(&Encoder{}).Encode(v) with Strict left off.
+func Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := marshalValue(&buf, reflect.ValueOf(v), false); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+//Unmarshal parses bencoded data into v, which must be a non-nil
+//pointer. It's a convenience wrapper around NewStream(bytes.NewReader(data)).Decode(v).
+func Unmarshal(data []byte, v interface{}) error {
+	return NewStream(bytes.NewReader(data)).Decode(v)
+}
+
+//marshalValue writes the bencode encoding of rv to w. Besides the types
+//Marshal documents, it also accepts the untyped interface{}/map[string]interface{}/
+//[]interface{} shapes Decoder.Decode produces, since rv.Kind() sees
+//through the interface to the concrete value in both cases.
+//
+//If strict is set, a Marshaler's output is required to be in bencode's
+//single canonical form (see checkCanonicalInt) rather than merely
+//well-formed.
+func marshalValue(w io.Writer, rv reflect.Value, strict bool) error {
+	if !rv.IsValid() {
+		return fmt.Errorf("bencode: can't marshal nil interface")
+	}
+	if (rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface) && rv.IsNil() {
+		return fmt.Errorf("bencode: can't marshal nil %v", rv.Type())
+	}
+
+	if m, ok := addrInterface(rv, marshalerType); ok {
+		b, err := m.(Marshaler).MarshalBencode()
+		if err != nil {
+			return err
+		}
+		if strict {
+			if err := checkCanonicalValue(b); err != nil {
+				return fmt.Errorf("bencode: non-canonical output from %T.MarshalBencode: %v", m, err)
+			}
+		}
+		_, err = w.Write(b)
+		return err
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return fmt.Errorf("bencode: can't marshal nil %v", rv.Type())
+		}
+		return marshalValue(w, rv.Elem(), strict)
+
+	case reflect.String:
+		return marshalByteString(w, []byte(rv.String()))
+
+	case reflect.Array, reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return marshalByteString(w, b)
+		}
+		if err := writeByte(w, 'l'); err != nil {
+			return err
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := marshalValue(w, rv.Index(i), strict); err != nil {
+				return err
+			}
+		}
+		return writeByte(w, 'e')
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		_, err := fmt.Fprintf(w, "i%de", rv.Int())
+		return err
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		_, err := fmt.Fprintf(w, "i%de", rv.Uint())
+		return err
+
+	case reflect.Struct:
+		return marshalStruct(w, rv, strict)
+
+	case reflect.Map:
+		return marshalMap(w, rv, strict)
+
+	default:
+		return fmt.Errorf("bencode: can't marshal %v", rv.Type())
+	}
+}
+
+func writeByte(w io.Writer, b byte) error {
+	_, err := w.Write([]byte{b})
+	return err
+}
+
+func marshalByteString(w io.Writer, b []byte) error {
+	if _, err := fmt.Fprintf(w, "%d:", len(b)); err != nil {
+		return err
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+//marshalStruct encodes rv's fields as a dict, sorted by the raw bytes
+//of their key as BEP-3 requires, skipping omitempty fields that hold a
+//zero value and rejecting fields whose tags collide on the same key.
+func marshalStruct(w io.Writer, rv reflect.Value, strict bool) error {
+	fields := cachedStructFields(rv.Type()).list
+
+	type entry struct {
+		name string
+		v    reflect.Value
+	}
+	entries := make([]entry, 0, len(fields))
+	for _, f := range fields {
+		fv := rv.FieldByIndex(f.index)
+		if f.omitempty && isEmptyValue(fv) {
+			continue
+		}
+		entries = append(entries, entry{f.name, fv})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare([]byte(entries[i].name), []byte(entries[j].name)) < 0
+	})
+	for i := 1; i < len(entries); i++ {
+		if entries[i].name == entries[i-1].name {
+			return fmt.Errorf("bencode: duplicate dict key %q in %v", entries[i].name, rv.Type())
+		}
+	}
+
+	if err := writeByte(w, 'd'); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := marshalByteString(w, []byte(e.name)); err != nil {
+			return err
+		}
+		if err := marshalValue(w, e.v, strict); err != nil {
+			return err
+		}
+	}
+	return writeByte(w, 'e')
+}
+
+//marshalMap encodes rv, a map with string-kinded keys, as a dict sorted
+//by the raw bytes of its keys.
+func marshalMap(w io.Writer, rv reflect.Value, strict bool) error {
+	if rv.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("bencode: can't marshal map with %v keys", rv.Type().Key())
+	}
+	keys := rv.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare([]byte(keys[i].String()), []byte(keys[j].String())) < 0
+	})
+
+	if err := writeByte(w, 'd'); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := marshalByteString(w, []byte(k.String())); err != nil {
+			return err
+		}
+		if err := marshalValue(w, rv.MapIndex(k), strict); err != nil {
+			return err
+		}
+	}
+	return writeByte(w, 'e')
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+//checkCanonicalValue reports whether b is exactly one well-formed,
+//canonical bencode value with no trailing data, by decoding it with a
+//Stream (whose Int rejects leading zeros and "-0" unconditionally).
+func checkCanonicalValue(b []byte) error {
+	s := NewStream(bytes.NewReader(b))
+	if err := s.skip(); err != nil {
+		return err
+	}
+	if extra, err := s.r.Peek(1); err == nil && len(extra) > 0 {
+		return fmt.Errorf("trailing data after value")
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package bencode
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+type marshalInner struct {
+	Name string `bencode:"name"`
+}
+
+type marshalOuter struct {
+	marshalInner
+	Pieces  []byte `bencode:"pieces"`
+	Length  int64  `bencode:"length,omitempty"`
+	Private bool   `bencode:"-"`
+	skipped string
+}
+
+func TestMarshalUnmarshalStructRoundTrip(t *testing.T) {
+	in := marshalOuter{
+		marshalInner: marshalInner{Name: "foo"},
+		Pieces:       []byte{0xde, 0xad},
+	}
+	b, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//Length is omitempty and zero, name sorts before pieces.
+	want := "d4:name3:foo6:pieces2:\xde\xade"
+	if string(b) != want {
+		t.Fatalf("Marshal() = %q; want %q", b, want)
+	}
+
+	var out marshalOuter
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	out.skipped = "" //unexported; Unmarshal must never touch it
+	in.skipped = ""
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("round trip = %+v; want %+v", out, in)
+	}
+}
+
+func TestMarshalOmitemptyIncludesNonZero(t *testing.T) {
+	b, err := Marshal(marshalOuter{Length: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte("6:lengthi5e")) {
+		t.Fatalf("Marshal() = %q; want it to contain the length field", b)
+	}
+}
+
+func TestMarshalByteStringVsString(t *testing.T) {
+	type both struct {
+		S string `bencode:"s"`
+		B []byte `bencode:"b"`
+	}
+	b, err := Marshal(both{S: "hi", B: []byte("hi")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "d1:b2:hi1:s2:hie"
+	if string(b) != want {
+		t.Fatalf("Marshal() = %q; want %q", b, want)
+	}
+}
+
+func TestUnmarshalEmptyContainers(t *testing.T) {
+	var v struct {
+		L []interface{}          `bencode:"l"`
+		D map[string]interface{} `bencode:"d"`
+	}
+	if err := Unmarshal([]byte("d1:dde1:llee"), &v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.L) != 0 || len(v.D) != 0 {
+		t.Fatalf("got L=%v D=%v; want both empty", v.L, v.D)
+	}
+}
+
+type hashField [4]byte
+
+func (h hashField) MarshalBencode() ([]byte, error) {
+	return []byte("4:" + string(h[:])), nil
+}
+
+func (h *hashField) UnmarshalBencode(raw []byte) error {
+	s := NewStream(bytes.NewReader(raw))
+	b, err := s.Bytes()
+	if err != nil {
+		return err
+	}
+	copy(h[:], b)
+	return nil
+}
+
+func TestMarshalerUnmarshalerRoundTrip(t *testing.T) {
+	in := hashField{'a', 'b', 'c', 'd'}
+	b, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "4:abcd" {
+		t.Fatalf("Marshal() = %q; want %q", b, "4:abcd")
+	}
+
+	var out hashField
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out != in {
+		t.Fatalf("Unmarshal() = %v; want %v", out, in)
+	}
+}
+
+func TestUnmarshalIgnoresUnknownKeys(t *testing.T) {
+	var v struct {
+		Name string `bencode:"name"`
+	}
+	if err := Unmarshal([]byte("d4:name3:foo5:otheri1ee"), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Name != "foo" {
+		t.Fatalf("Name = %q; want %q", v.Name, "foo")
+	}
+}
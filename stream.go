@@ -0,0 +1,649 @@
+package bencode
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+//DefaultMaxStringLen is the length prefix Stream.Bytes refuses to
+//exceed when Stream.MaxStringLen is left at zero. It bounds how large
+//an allocation a single malicious length prefix can force before any
+//of the string's data has even arrived.
+const DefaultMaxStringLen = 1 << 31 //2 GiB
+
+//Kind identifies the type of the next token available from a Stream.
+type Kind int
+
+const (
+	Int Kind = iota
+	String
+	List
+	Dict
+	End
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Int:
+		return "Int"
+	case String:
+		return "String"
+	case List:
+		return "List"
+	case Dict:
+		return "Dict"
+	case End:
+		return "End"
+	}
+	return "Unknown"
+}
+
+//A Stream reads bencoded tokens from an io.Reader without requiring the
+//whole input to be buffered up front, which makes it suitable for
+//multi-GB .torrent files or network streams where a Decoder's []byte
+//based approach doesn't scale.
+//
+//Stream exposes a token-level API similar in spirit to encoding/gob or
+//the RLP Stream decoder: Kind peeks at the next token, Int/Bytes consume
+//a scalar, and List/Dict descend into a container while ListEnd/DictEnd
+//ascend back out of it.
+//
+//Example usage:
+//	s := bencode.NewStream(r)
+//	if err := s.Dict(); err != nil { ... }
+//	for {
+//		kind, err := s.Kind()
+//		if err != nil { ... }
+//		if kind == bencode.End {
+//			break
+//		}
+//		key, _ := s.Bytes()
+//		...
+//	}
+//	s.DictEnd()
+type Stream struct {
+	r     *bufio.Reader
+	stack []Kind        //kinds of the containers we're currently inside of
+	buf   []byte        //scratch buffer reused by Int/Bytes to avoid reallocating
+	rec   *bytes.Buffer //if non-nil, every byte read is also copied here; see Raw
+
+	//MaxStringLen caps the length prefix Bytes will accept, so a bogus
+	//or hostile prefix can't force a huge allocation before its data
+	//has arrived. Zero means DefaultMaxStringLen.
+	MaxStringLen int
+}
+
+func (s *Stream) maxStringLen() int {
+	if s.MaxStringLen > 0 {
+		return s.MaxStringLen
+	}
+	return DefaultMaxStringLen
+}
+
+//NewStream creates a new Stream reading bencoded tokens from r.
+func NewStream(r io.Reader) *Stream {
+	return &Stream{r: bufio.NewReader(r)}
+}
+
+//Kind reports the type of the next token in the stream without
+//consuming it. If the stream is positioned at the end of the
+//currently-open list or dict, Kind returns End; callers must then call
+//ListEnd or DictEnd to ascend out of the container.
+func (s *Stream) Kind() (Kind, error) {
+	b, err := s.r.Peek(1)
+	if err != nil {
+		if err == io.EOF && len(s.stack) > 0 {
+			return 0, fmt.Errorf("unexpected EOF inside %v", s.top())
+		}
+		return 0, err
+	}
+	switch c := b[0]; {
+	case c == 'e':
+		if len(s.stack) == 0 {
+			return 0, fmt.Errorf("unexpected 'e' outside any list or dict")
+		}
+		return End, nil
+	case c == 'i':
+		return Int, nil
+	case c >= '0' && c <= '9':
+		return String, nil
+	case c == 'l':
+		return List, nil
+	case c == 'd':
+		return Dict, nil
+	default:
+		return 0, fmt.Errorf("invalid token start byte %q", c)
+	}
+}
+
+func (s *Stream) top() Kind {
+	if len(s.stack) == 0 {
+		return -1
+	}
+	return s.stack[len(s.stack)-1]
+}
+
+//readByte reads and returns a single byte, translating io.EOF into a
+//more descriptive error since a well-formed token is never truncated.
+func (s *Stream) readByte() (byte, error) {
+	b, err := s.r.ReadByte()
+	if err != nil {
+		if err == io.EOF {
+			return 0, fmt.Errorf("unexpected EOF reading token")
+		}
+		return 0, err
+	}
+	if s.rec != nil {
+		s.rec.WriteByte(b)
+	}
+	return b, nil
+}
+
+//Int consumes an 'i...e' token and returns its value. It rejects leading
+//zeros (other than the literal "0") and "-0", mirroring the BEP-3 rule
+//that integers have a single canonical encoding.
+func (s *Stream) Int() (int64, error) {
+	if c, err := s.readByte(); err != nil {
+		return 0, err
+	} else if c != 'i' {
+		return 0, fmt.Errorf("expected 'i', got %q", c)
+	}
+
+	s.buf = s.buf[:0]
+	for {
+		c, err := s.readByte()
+		if err != nil {
+			return 0, err
+		}
+		if c == 'e' {
+			break
+		}
+		if (c < '0' || c > '9') && c != '-' {
+			return 0, fmt.Errorf("invalid byte %q in encoded integer", c)
+		}
+		s.buf = append(s.buf, c)
+	}
+
+	if err := checkCanonicalInt(s.buf); err != nil {
+		return 0, err
+	}
+
+	v, err := strconv.ParseInt(string(s.buf), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("integer token %q: %v", s.buf, err)
+	}
+	return v, nil
+}
+
+//checkCanonicalInt rejects "-0" and leading zeros other than "0" itself.
+func checkCanonicalInt(digits []byte) error {
+	if len(digits) == 0 {
+		return fmt.Errorf("empty integer token")
+	}
+	d := digits
+	if d[0] == '-' {
+		if len(d) == 1 {
+			return fmt.Errorf("invalid integer token %q", digits)
+		}
+		if d[1] == '0' {
+			return fmt.Errorf("integer token %q has a negative zero", digits)
+		}
+		d = d[1:]
+	}
+	if len(d) > 1 && d[0] == '0' {
+		return fmt.Errorf("integer token %q has a leading zero", digits)
+	}
+	return nil
+}
+
+//Bytes consumes a "<len>:<data>" token and returns its data. The
+//returned slice is only valid until the next call into the Stream;
+//callers that need to retain it must copy it.
+func (s *Stream) Bytes() ([]byte, error) {
+	n, err := s.readStringLen()
+	if err != nil {
+		return nil, err
+	}
+	if cap(s.buf) < n {
+		s.buf = make([]byte, n)
+	}
+	buf := s.buf[:n]
+	if _, err := io.ReadFull(s.r, buf); err != nil {
+		return nil, fmt.Errorf("reading %d byte string: %v", n, err)
+	}
+	if s.rec != nil {
+		s.rec.Write(buf)
+	}
+	return buf, nil
+}
+
+//readStringLen reads the "<len>:" prefix of a string token and returns
+//len. It rejects a prefix above math.MaxInt32 while accumulating it
+//(so it can never overflow int), and one that exceeds maxStringLen, before the caller gets anywhere near
+//allocating a buffer for it — a peer sending "9000000000000000000:x"
+//must not be able to force a multi-GB (or negative-length) allocation.
+func (s *Stream) readStringLen() (int, error) {
+	c, err := s.readByte()
+	if err != nil {
+		return 0, err
+	}
+	if c < '0' || c > '9' {
+		return 0, fmt.Errorf("expected string length, got %q", c)
+	}
+	n := int(c - '0')
+	for {
+		c, err := s.readByte()
+		if err != nil {
+			return 0, err
+		}
+		if c == ':' {
+			break
+		}
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid byte %q in string length", c)
+		}
+		d := int(c - '0')
+		if n > (math.MaxInt32-d)/10 {
+			return 0, fmt.Errorf("string length prefix overflows")
+		}
+		n = n*10 + d
+	}
+	if n > s.maxStringLen() {
+		return 0, fmt.Errorf("string length %d exceeds maximum of %d", n, s.maxStringLen())
+	}
+	return n, nil
+}
+
+//List consumes the opening 'l' of a list token and descends into it.
+//Callers must read elements with Kind/Int/Bytes/List/Dict until Kind
+//reports End, then call ListEnd to ascend back out.
+func (s *Stream) List() error {
+	c, err := s.readByte()
+	if err != nil {
+		return err
+	}
+	if c != 'l' {
+		return fmt.Errorf("expected 'l', got %q", c)
+	}
+	s.stack = append(s.stack, List)
+	return nil
+}
+
+//ListEnd consumes the closing 'e' of the list currently open and
+//ascends back out of it. It is an error to call ListEnd while not
+//directly inside a list opened with List.
+func (s *Stream) ListEnd() error {
+	return s.containerEnd(List)
+}
+
+//Dict consumes the opening 'd' of a dict token and descends into it.
+//Callers must read alternating key/value pairs with Bytes for the key
+//and any token for the value until Kind reports End, then call DictEnd
+//to ascend back out.
+func (s *Stream) Dict() error {
+	c, err := s.readByte()
+	if err != nil {
+		return err
+	}
+	if c != 'd' {
+		return fmt.Errorf("expected 'd', got %q", c)
+	}
+	s.stack = append(s.stack, Dict)
+	return nil
+}
+
+//DictEnd consumes the closing 'e' of the dict currently open and
+//ascends back out of it. It is an error to call DictEnd while not
+//directly inside a dict opened with Dict.
+func (s *Stream) DictEnd() error {
+	return s.containerEnd(Dict)
+}
+
+func (s *Stream) containerEnd(want Kind) error {
+	if s.top() != want {
+		return fmt.Errorf("mismatched end: inside %v, not %v", s.top(), want)
+	}
+	c, err := s.readByte()
+	if err != nil {
+		return err
+	}
+	if c != 'e' {
+		return fmt.Errorf("expected 'e', got %q", c)
+	}
+	s.stack = s.stack[:len(s.stack)-1]
+	return nil
+}
+
+//skip consumes and discards the next complete token, descending into
+//lists and dicts to discard their contents too. It's used to skip dict
+//keys a struct has no matching field for, and to implement Raw.
+func (s *Stream) skip() error {
+	kind, err := s.Kind()
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case Int:
+		_, err := s.Int()
+		return err
+
+	case String:
+		_, err := s.Bytes()
+		return err
+
+	case List:
+		if err := s.List(); err != nil {
+			return err
+		}
+		for {
+			kind, err := s.Kind()
+			if err != nil {
+				return err
+			}
+			if kind == End {
+				return s.ListEnd()
+			}
+			if err := s.skip(); err != nil {
+				return err
+			}
+		}
+
+	case Dict:
+		if err := s.Dict(); err != nil {
+			return err
+		}
+		for {
+			kind, err := s.Kind()
+			if err != nil {
+				return err
+			}
+			if kind == End {
+				return s.DictEnd()
+			}
+			if _, err := s.Bytes(); err != nil { //key
+				return err
+			}
+			if err := s.skip(); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("bencode: unexpected token kind %v", kind)
+	}
+}
+
+//Raw consumes the next complete token and returns its exact bencode
+//encoding, unparsed. Unmarshal uses it to hand types implementing
+//Unmarshaler their raw bytes; callers that need to preserve a value's
+//original byte representation (e.g. to hash a BitTorrent info dict) can
+//use it directly.
+func (s *Stream) Raw() ([]byte, error) {
+	var buf bytes.Buffer
+	prev := s.rec
+	s.rec = &buf
+	err := s.skip()
+	s.rec = prev
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+//Decode reads one bencoded value from the stream into v, which must be
+//a non-nil pointer. It follows the same rules as Unmarshal: struct
+//fields are matched via `bencode` tags, types implementing Unmarshaler
+//are handed the raw bytes of their value, and *interface{} produces the
+//same string/[]interface{}/map[string]interface{} shapes as
+//Decoder.Decode, except that integers are int64 rather than int.
+func (s *Stream) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("bencode: Decode requires a non-nil pointer, got %T", v)
+	}
+	return s.decodeValue(rv.Elem())
+}
+
+//decodeValue decodes the next token into rv, which must be addressable
+//and settable.
+func (s *Stream) decodeValue(rv reflect.Value) error {
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return s.decodeValue(rv.Elem())
+	}
+
+	if u, ok := addrInterface(rv, unmarshalerType); ok {
+		raw, err := s.Raw()
+		if err != nil {
+			return err
+		}
+		return u.(Unmarshaler).UnmarshalBencode(raw)
+	}
+
+	if rv.Kind() == reflect.Interface && rv.NumMethod() == 0 {
+		v, err := s.decodeAny()
+		if err != nil {
+			return err
+		}
+		rv.Set(reflect.ValueOf(v))
+		return nil
+	}
+
+	if rv.Kind() == reflect.Struct {
+		return s.decodeStruct(rv)
+	}
+
+	if rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8 {
+		b, err := s.Bytes()
+		if err != nil {
+			return err
+		}
+		if len(b) != rv.Len() {
+			return fmt.Errorf("bencode: can't decode %d-byte string into %v", len(b), rv.Type())
+		}
+		reflect.Copy(rv, reflect.ValueOf(b))
+		return nil
+	}
+
+	kind, err := s.Kind()
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case Int:
+		i, err := s.Int()
+		if err != nil {
+			return err
+		}
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			rv.SetInt(i)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			rv.SetUint(uint64(i))
+		default:
+			return fmt.Errorf("bencode: can't decode Int into %v", rv.Type())
+		}
+		return nil
+
+	case String:
+		b, err := s.Bytes()
+		if err != nil {
+			return err
+		}
+		switch {
+		case rv.Kind() == reflect.String:
+			rv.SetString(string(b))
+		case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+			cp := make([]byte, len(b))
+			copy(cp, b)
+			rv.SetBytes(cp)
+		default:
+			return fmt.Errorf("bencode: can't decode String into %v", rv.Type())
+		}
+		return nil
+
+	case List:
+		if rv.Kind() != reflect.Slice {
+			return fmt.Errorf("bencode: can't decode List into %v", rv.Type())
+		}
+		if err := s.List(); err != nil {
+			return err
+		}
+		rv.Set(reflect.MakeSlice(rv.Type(), 0, 0))
+		for {
+			kind, err := s.Kind()
+			if err != nil {
+				return err
+			}
+			if kind == End {
+				return s.ListEnd()
+			}
+			elem := reflect.New(rv.Type().Elem()).Elem()
+			if err := s.decodeValue(elem); err != nil {
+				return err
+			}
+			rv.Set(reflect.Append(rv, elem))
+		}
+
+	case Dict:
+		if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("bencode: can't decode Dict into %v", rv.Type())
+		}
+		if err := s.Dict(); err != nil {
+			return err
+		}
+		rv.Set(reflect.MakeMap(rv.Type()))
+		for {
+			kind, err := s.Kind()
+			if err != nil {
+				return err
+			}
+			if kind == End {
+				return s.DictEnd()
+			}
+			key, err := s.Bytes()
+			if err != nil {
+				return err
+			}
+			elem := reflect.New(rv.Type().Elem()).Elem()
+			if err := s.decodeValue(elem); err != nil {
+				return err
+			}
+			rv.SetMapIndex(reflect.ValueOf(string(key)).Convert(rv.Type().Key()), elem)
+		}
+
+	default:
+		return fmt.Errorf("bencode: unexpected token kind %v", kind)
+	}
+}
+
+//decodeStruct decodes a dict into rv, a struct value, matching keys
+//against the `bencode` tags resolved by cachedStructFields. Keys with
+//no matching field are skipped rather than rejected, so callers can
+//decode a subset of a larger dict.
+func (s *Stream) decodeStruct(rv reflect.Value) error {
+	if err := s.Dict(); err != nil {
+		return err
+	}
+	fields := cachedStructFields(rv.Type())
+	for {
+		kind, err := s.Kind()
+		if err != nil {
+			return err
+		}
+		if kind == End {
+			return s.DictEnd()
+		}
+		key, err := s.Bytes()
+		if err != nil {
+			return err
+		}
+		f := fields.find(string(key))
+		if f == nil {
+			if err := s.skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := s.decodeValue(rv.FieldByIndex(f.index)); err != nil {
+			return err
+		}
+	}
+}
+
+//decodeAny decodes the next token into int64, string, []interface{},
+//or map[string]interface{}: the generic shapes Decoder.Decode produces,
+//except that Decoder.Decode returns integers as int.
+func (s *Stream) decodeAny() (interface{}, error) {
+	kind, err := s.Kind()
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case Int:
+		return s.Int()
+
+	case String:
+		b, err := s.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+
+	case List:
+		if err := s.List(); err != nil {
+			return nil, err
+		}
+		list := []interface{}{}
+		for {
+			kind, err := s.Kind()
+			if err != nil {
+				return nil, err
+			}
+			if kind == End {
+				return list, s.ListEnd()
+			}
+			v, err := s.decodeAny()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+
+	case Dict:
+		if err := s.Dict(); err != nil {
+			return nil, err
+		}
+		dict := map[string]interface{}{}
+		for {
+			kind, err := s.Kind()
+			if err != nil {
+				return nil, err
+			}
+			if kind == End {
+				return dict, s.DictEnd()
+			}
+			key, err := s.Bytes()
+			if err != nil {
+				return nil, err
+			}
+			v, err := s.decodeAny()
+			if err != nil {
+				return nil, err
+			}
+			dict[string(key)] = v
+		}
+
+	default:
+		return nil, fmt.Errorf("bencode: unexpected token kind %v", kind)
+	}
+}
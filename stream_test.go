@@ -0,0 +1,167 @@
+package bencode
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStreamInt(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"i0e", 0, false},
+		{"i23e", 23, false},
+		{"i-23e", -23, false},
+		{"i-0e", 0, true},                         //negative zero isn't canonical
+		{"i00e", 0, true},                         //leading zero isn't canonical
+		{"i023e", 0, true},                        //leading zero isn't canonical
+		{"i-03e", 0, true},                        //leading zero isn't canonical
+		{"ie", 0, true},                           //empty integer
+		{"i12", 0, true},                          //missing 'e'
+		{"i12x", 0, true},                         //invalid digit
+		{"i99999999999999999999999999e", 0, true}, //overflows int64
+	}
+	for _, c := range cases {
+		s := NewStream(strings.NewReader(c.in))
+		got, err := s.Int()
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("Int(%q) = %d, nil; want error", c.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Int(%q) unexpected error: %v", c.in, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("Int(%q) = %d; want %d", c.in, got, c.want)
+		}
+	}
+}
+
+func TestStreamBytes(t *testing.T) {
+	s := NewStream(strings.NewReader("5:hello"))
+	b, err := s.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hello" {
+		t.Errorf("Bytes() = %q; want %q", b, "hello")
+	}
+}
+
+func TestStreamBytesRejectsHugeLengthPrefix(t *testing.T) {
+	//A bogus length prefix must be rejected before Bytes tries to
+	//allocate or read anything backing it.
+	s := NewStream(strings.NewReader("9000000000000000000:x"))
+	if _, err := s.Bytes(); err == nil {
+		t.Fatal("Bytes() with a huge length prefix = nil error; want error")
+	}
+}
+
+func TestStreamBytesRejectsOversizedLength(t *testing.T) {
+	s := NewStream(strings.NewReader("1000:x"))
+	s.MaxStringLen = 10
+	if _, err := s.Bytes(); err == nil {
+		t.Fatal("Bytes() over MaxStringLen = nil error; want error")
+	}
+}
+
+func TestStreamListAndDict(t *testing.T) {
+	s := NewStream(strings.NewReader("d3:fool3:bar3:baze3:inti42ee"))
+	if err := s.Dict(); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := s.Bytes()
+	if err != nil || string(key) != "foo" {
+		t.Fatalf("key = %q, %v; want \"foo\", nil", key, err)
+	}
+	if err := s.List(); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for {
+		kind, err := s.Kind()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kind == End {
+			break
+		}
+		v, err := s.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(v))
+	}
+	if err := s.ListEnd(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"bar", "baz"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("list = %v; want %v", got, want)
+	}
+
+	key, err = s.Bytes()
+	if err != nil || string(key) != "int" {
+		t.Fatalf("key = %q, %v; want \"int\", nil", key, err)
+	}
+	n, err := s.Int()
+	if err != nil || n != 42 {
+		t.Fatalf("Int() = %d, %v; want 42, nil", n, err)
+	}
+	if err := s.DictEnd(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamTruncatedInput(t *testing.T) {
+	cases := []string{
+		"d4:infol1:a", //unterminated list inside a dict value
+		"l1:a",        //unterminated list
+		"d3:foo",      //dict with a key but no value
+		"5:ab",        //string shorter than its length prefix
+	}
+	for _, c := range cases {
+		s := NewStream(strings.NewReader(c))
+		var v interface{}
+		if err := s.Decode(&v); err == nil {
+			t.Errorf("Decode(%q) = nil error; want error", c)
+		}
+	}
+}
+
+func TestStreamMismatchedContainerEnd(t *testing.T) {
+	s := NewStream(strings.NewReader("l1:ae"))
+	if err := s.List(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Bytes(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DictEnd(); err == nil {
+		t.Fatal("DictEnd() inside a list = nil error; want error")
+	}
+}
+
+func TestStreamRaw(t *testing.T) {
+	s := NewStream(strings.NewReader("d4:infod4:name3:fooee"))
+	if err := s.Dict(); err != nil {
+		t.Fatal(err)
+	}
+	key, err := s.Bytes()
+	if err != nil || string(key) != "info" {
+		t.Fatalf("key = %q, %v; want \"info\", nil", key, err)
+	}
+	raw, err := s.Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != "d4:name3:fooe" {
+		t.Errorf("Raw() = %q; want %q", raw, "d4:name3:fooe")
+	}
+}
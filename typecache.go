@@ -0,0 +1,100 @@
+package bencode
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+//field describes one struct field that participates in bencode
+//encoding/decoding: the dict key it's matched against, the path to
+//reach it (FieldByIndex, so embedded fields are included), and whether
+//a zero value should be omitted on encode.
+type field struct {
+	name      string
+	index     []int
+	omitempty bool
+}
+
+//structFields is the resolved field list for a struct type, searched
+//by name with find, computed once and cached in typeCache.
+type structFields struct {
+	list []field
+}
+
+func (sf *structFields) find(name string) *field {
+	for i := range sf.list {
+		if sf.list[i].name == name {
+			return &sf.list[i]
+		}
+	}
+	return nil
+}
+
+var (
+	typeCacheMu sync.RWMutex
+	typeCache   = make(map[reflect.Type]*structFields)
+)
+
+//cachedStructFields returns the structFields for t, building and
+//caching it on first use. This mirrors the typecache used by
+//encoding/gob and RLP so repeated Marshal/Unmarshal calls for the same
+//struct type don't re-walk its fields every time.
+func cachedStructFields(t reflect.Type) *structFields {
+	typeCacheMu.RLock()
+	f := typeCache[t]
+	typeCacheMu.RUnlock()
+	if f != nil {
+		return f
+	}
+
+	f = buildStructFields(t)
+
+	typeCacheMu.Lock()
+	typeCache[t] = f
+	typeCacheMu.Unlock()
+	return f
+}
+
+//buildStructFields walks t's fields, honoring `bencode:"name,omitempty"`
+//tags and flattening anonymous struct fields so they behave as if their
+//fields were declared directly on t.
+func buildStructFields(t reflect.Type) *structFields {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue //unexported
+		}
+
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			for _, ef := range buildStructFields(sf.Type).list {
+				fields = append(fields, field{
+					name:      ef.name,
+					index:     append([]int{i}, ef.index...),
+					omitempty: ef.omitempty,
+				})
+			}
+			continue
+		}
+
+		name := sf.Name
+		omitempty := false
+		if tag := sf.Tag.Get("bencode"); tag != "" {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" && len(parts) == 1 {
+				continue
+			}
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, opt := range parts[1:] {
+				if opt == "omitempty" {
+					omitempty = true
+				}
+			}
+		}
+		fields = append(fields, field{name: name, index: []int{i}, omitempty: omitempty})
+	}
+	return &structFields{list: fields}
+}